@@ -0,0 +1,66 @@
+package unbound
+
+import (
+	"context"
+
+	"github.com/mangadex-pub/go-libunbound"
+)
+
+// newCtxPair creates a fresh, empty pair of libunbound contexts (UDP and
+// TCP-upstream). Callers are responsible for replaying any option/config/
+// anchor state into the returned pair.
+func newCtxPair() *unboundCtx {
+	udp := unbound.New()
+	tcp := unbound.New()
+	tcp.SetOption("tcp-upstream:", "yes")
+
+	return &unboundCtx{u: udp, t: tcp}
+}
+
+// asyncResolver is the subset of go-libunbound's *unbound.Unbound API that
+// resolve needs, broken out so tests can exercise cancellation/completion
+// without linking against libunbound itself.
+type asyncResolver interface {
+	ResolveAsync(name string, rrtype, rrclass uint16, c chan *unbound.ResultError)
+}
+
+// asyncResult carries the outcome of a single ResolveAsync call back to the
+// goroutine waiting on it in resolve.
+type asyncResult struct {
+	res *unbound.Result
+	err error
+}
+
+// resolve issues an async query against ub and waits for either its result or
+// ctx cancellation.
+//
+// go-libunbound's ResolveAsync has no Cancel, Wait or Poll (its own doc
+// comment says so outright) - once a query is submitted there is no way to
+// abort it inside libunbound. So on ctx cancellation resolve gives up waiting
+// and returns ctx.Err() immediately, but the query keeps running in the
+// background until libunbound actually answers on the channel; done is called
+// then, not when resolve returns, so a caller bounding concurrency (the
+// "inflight" directive) still reflects queries really outstanding against
+// libunbound rather than ones it merely stopped waiting for.
+func resolve(ctx context.Context, ub asyncResolver, name string, qtype, qclass uint16, done func()) (*unbound.Result, error) {
+	if done == nil {
+		done = func() {}
+	}
+
+	ch := make(chan *unbound.ResultError, 1)
+	ub.ResolveAsync(name, qtype, qclass, ch)
+
+	resCh := make(chan asyncResult, 1)
+	go func() {
+		re := <-ch
+		done()
+		resCh <- asyncResult{res: re.Result, err: re.Error}
+	}()
+
+	select {
+	case r := <-resCh:
+		return r.res, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,125 @@
+package unbound
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/mangadex-pub/go-libunbound"
+)
+
+func TestUnboundParseInflight(t *testing.T) {
+	c := caddy.NewTestController("dns", `unbound example.org {
+		inflight 5
+	}`)
+	u, err := unboundParse(c)
+	if err != nil {
+		t.Fatalf("unboundParse returned error: %s", err)
+	}
+	if cap(u.inflight) != 5 {
+		t.Errorf("cap(u.inflight) = %d, want 5", cap(u.inflight))
+	}
+
+	if _, err := unboundParse(caddy.NewTestController("dns", `unbound example.org {
+		inflight 0
+	}`)); err == nil {
+		t.Fatalf("unboundParse succeeded with inflight 0, wanted an error")
+	}
+}
+
+// fakeAsyncResolver stands in for *unbound.Unbound in tests, capturing the
+// channel ResolveAsync was given so the test controls exactly when (and with
+// what) it's answered.
+type fakeAsyncResolver struct {
+	ch chan *unbound.ResultError
+}
+
+func (f *fakeAsyncResolver) ResolveAsync(name string, rrtype, rrclass uint16, c chan *unbound.ResultError) {
+	f.ch = c
+}
+
+func TestResolveCompletes(t *testing.T) {
+	ub := &fakeAsyncResolver{}
+	var doneCalled bool
+	done := func() { doneCalled = true }
+
+	resCh := make(chan struct {
+		res *unbound.Result
+		err error
+	}, 1)
+	go func() {
+		res, err := resolve(context.Background(), ub, "example.org.", 1, 1, done)
+		resCh <- struct {
+			res *unbound.Result
+			err error
+		}{res, err}
+	}()
+
+	// Wait for resolve to have called ResolveAsync and captured the channel.
+	var ch chan *unbound.ResultError
+	for i := 0; i < 1000 && ch == nil; i++ {
+		ch = ub.ch
+		if ch == nil {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if ch == nil {
+		t.Fatal("resolve never called ResolveAsync")
+	}
+
+	want := &unbound.Result{}
+	ch <- &unbound.ResultError{Result: want}
+
+	got := <-resCh
+	if got.res != want || got.err != nil {
+		t.Errorf("resolve returned (%v, %v), want (%v, nil)", got.res, got.err, want)
+	}
+	if !doneCalled {
+		t.Error("done was not called on normal completion")
+	}
+}
+
+func TestResolveCancellation(t *testing.T) {
+	ub := &fakeAsyncResolver{}
+	doneCh := make(chan struct{}, 1)
+	done := func() { doneCh <- struct{}{} }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resCh := make(chan error, 1)
+	go func() {
+		_, err := resolve(ctx, ub, "example.org.", 1, 1, done)
+		resCh <- err
+	}()
+
+	var ch chan *unbound.ResultError
+	for i := 0; i < 1000 && ch == nil; i++ {
+		ch = ub.ch
+		if ch == nil {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if ch == nil {
+		t.Fatal("resolve never called ResolveAsync")
+	}
+
+	cancel()
+	if err := <-resCh; err != context.Canceled {
+		t.Errorf("resolve returned err = %v, want context.Canceled", err)
+	}
+
+	select {
+	case <-doneCh:
+		t.Fatal("done was called before libunbound actually answered")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// libunbound answers late, after resolve already gave up waiting: done
+	// must still fire so inflight accounting reflects the query completing.
+	ch <- &unbound.ResultError{Result: &unbound.Result{}}
+	select {
+	case <-doneCh:
+	case <-time.After(time.Second):
+		t.Fatal("done was never called after the late answer arrived")
+	}
+}
@@ -0,0 +1,122 @@
+package unbound
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// forwardZone is a single "forward" Corefile block: a libunbound forward-zone
+// together with the upstreams it should be forwarded to.
+type forwardZone struct {
+	zone      string
+	upstreams []forwardUpstream
+}
+
+// forwardUpstream is a single upstream ("addr" line) inside a forward block.
+type forwardUpstream struct {
+	addr        string // host[@port], an IP, or (DoH) a https:// URL
+	tlsAuthName string
+	tls         bool
+	doh         bool
+}
+
+// addForward applies fz to u's live context and records it on u.forwardZones
+// so reload.go's buildCtx can replay it, in order, on a freshly built
+// unboundCtx. Unlike plain options, forward zones can't be flattened into
+// u.opts: a second zone (or a second addr in one zone) would overwrite the
+// first in that map, and map iteration order would scramble the stateful
+// "forward-zone:"/"name:"/"forward-addr:" sequence libunbound expects.
+func (u *Unbound) addForward(fz forwardZone) error {
+	if err := applyForward(u.ctx.Load(), fz); err != nil {
+		return err
+	}
+	u.forwardZones = append(u.forwardZones, fz)
+	return nil
+}
+
+// applyForward drives libunbound's forward-zone option sequence on c, as
+// documented in unbound.conf(5): a "forward-zone:" section opener, its
+// "name:", then one "forward-addr:" (plus optional "forward-tls-upstream:")
+// per upstream. unbound.conf(5) has no standalone "tls-auth-name:" option -
+// the auth name is instead embedded directly in the forward-addr value as
+// "ip@port#authname".
+func applyForward(c *unboundCtx, fz forwardZone) error {
+	if err := c.set("forward-zone", ""); err != nil {
+		return fmt.Errorf("failed to start forward-zone for %s: %s", fz.zone, err)
+	}
+	if err := c.set("name", fz.zone); err != nil {
+		return fmt.Errorf("failed to set forward-zone name %s: %s", fz.zone, err)
+	}
+
+	for _, up := range fz.upstreams {
+		addr := up.addr
+		if up.tlsAuthName != "" {
+			addr += "#" + up.tlsAuthName
+		}
+		if err := c.set("forward-addr", addr); err != nil {
+			return fmt.Errorf("failed to add forward-addr %s for zone %s: %s", addr, fz.zone, err)
+		}
+		// DoH rides over TLS, so a "doh" upstream also needs forward-tls-upstream set.
+		if up.tls || up.doh {
+			if err := c.set("forward-tls-upstream", "yes"); err != nil {
+				return fmt.Errorf("failed to enable TLS for zone %s: %s", fz.zone, err)
+			}
+		}
+	}
+	return nil
+}
+
+// bootstrap resolves host (the upstream's configured hostname) by querying
+// bootstrapAddr directly for its A record, then substitutes the resolved IP
+// into fu.addr while keeping the original hostname as the TLS auth name (SNI)
+// if one wasn't already configured explicitly. This lets a DoT/DoH upstream be
+// configured by hostname without a chicken-and-egg dependency on a resolver
+// that isn't up yet - the same trick AdGuardHome uses to bootstrap upstreams.
+//
+// It only supports the "host[@port]"/IP forms libunbound's forward-addr takes;
+// a DoH upstream configured as a URL (e.g. "https://dns.google/dns-query") has
+// no such host[@port] to substitute an IP into, so it's rejected outright
+// rather than bootstrapping the URL string itself as if it were a hostname.
+func (fu *forwardUpstream) bootstrap(bootstrapAddr string) error {
+	if strings.Contains(fu.addr, "://") {
+		return fmt.Errorf("bootstrap does not support URL-style addr %q; configure it with an already-resolved IP instead", fu.addr)
+	}
+
+	host, port, found := strings.Cut(fu.addr, "@")
+	if net.ParseIP(host) != nil {
+		return nil // already an IP, nothing to bootstrap
+	}
+
+	if _, _, err := net.SplitHostPort(bootstrapAddr); err != nil {
+		bootstrapAddr = net.JoinHostPort(bootstrapAddr, "53")
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(host), dns.TypeA)
+	in, err := dns.Exchange(m, bootstrapAddr)
+	if err != nil {
+		return fmt.Errorf("bootstrap lookup of %s via %s failed: %s", host, bootstrapAddr, err)
+	}
+	var ip string
+	for _, rr := range in.Answer {
+		if a, ok := rr.(*dns.A); ok {
+			ip = a.A.String()
+			break
+		}
+	}
+	if ip == "" {
+		return fmt.Errorf("bootstrap lookup of %s via %s returned no A record", host, bootstrapAddr)
+	}
+
+	if fu.tlsAuthName == "" {
+		fu.tlsAuthName = host
+	}
+	fu.addr = ip
+	if found {
+		fu.addr += "@" + port
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package unbound
+
+import (
+	"net"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestUnboundParseForward(t *testing.T) {
+	input := `unbound example.org {
+		forward example.org {
+			addr 1.1.1.1@853
+			tls-auth-name cloudflare-dns.com
+			tls yes
+		}
+		forward . {
+			addr https://dns.google/dns-query
+			doh yes
+		}
+	}`
+
+	c := caddy.NewTestController("dns", input)
+	u, err := unboundParse(c)
+	if err != nil {
+		t.Fatalf("unboundParse returned error: %s", err)
+	}
+
+	if len(u.forwardZones) != 2 {
+		t.Fatalf("got %d forward zones, want 2", len(u.forwardZones))
+	}
+
+	fz := u.forwardZones[0]
+	if fz.zone != "example.org." {
+		t.Errorf("zone = %q, want %q", fz.zone, "example.org.")
+	}
+	if len(fz.upstreams) != 1 {
+		t.Fatalf("got %d upstreams, want 1", len(fz.upstreams))
+	}
+	up := fz.upstreams[0]
+	if up.addr != "1.1.1.1@853" || up.tlsAuthName != "cloudflare-dns.com" || !up.tls {
+		t.Errorf("upstream = %+v, want addr=1.1.1.1@853 tlsAuthName=cloudflare-dns.com tls=true", up)
+	}
+
+	fz = u.forwardZones[1]
+	if fz.zone != "." {
+		t.Errorf("zone = %q, want %q", fz.zone, ".")
+	}
+	if !fz.upstreams[0].doh {
+		t.Errorf("upstream.doh = false, want true")
+	}
+}
+
+func TestUnboundParseForwardErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name: "tls without a preceding addr",
+			input: `unbound example.org {
+				forward example.org {
+					tls yes
+				}
+			}`,
+		},
+		{
+			name: "unknown sub-directive",
+			input: `unbound example.org {
+				forward example.org {
+					bogus 1.1.1.1
+				}
+			}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := caddy.NewTestController("dns", tc.input)
+			if _, err := unboundParse(c); err == nil {
+				t.Fatalf("unboundParse succeeded, wanted an error")
+			}
+		})
+	}
+}
+
+// testBootstrapServer starts a minimal UDP DNS server answering every A query
+// for host with ip, and returns its "ip:port" address for use as a bootstrap
+// resolver.
+func testBootstrapServer(t *testing.T, host, ip string) string {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start test bootstrap server: %s", err)
+	}
+
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if len(r.Question) == 1 && r.Question[0].Qtype == dns.TypeA && r.Question[0].Name == dns.Fqdn(host) {
+			m.Answer = []dns.RR{&dns.A{
+				Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.ParseIP(ip),
+			}}
+		}
+		w.WriteMsg(m)
+	})}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+
+	return pc.LocalAddr().String()
+}
+
+func TestForwardUpstreamBootstrap(t *testing.T) {
+	bootstrapAddr := testBootstrapServer(t, "dot.example.com", "203.0.113.1")
+
+	up := forwardUpstream{addr: "dot.example.com@853"}
+	if err := up.bootstrap(bootstrapAddr); err != nil {
+		t.Fatalf("bootstrap returned error: %s", err)
+	}
+	if up.addr != "203.0.113.1@853" {
+		t.Errorf("addr = %q, want %q", up.addr, "203.0.113.1@853")
+	}
+	if up.tlsAuthName != "dot.example.com" {
+		t.Errorf("tlsAuthName = %q, want %q (SNI should default to the original hostname)", up.tlsAuthName, "dot.example.com")
+	}
+}
+
+func TestForwardUpstreamBootstrapPreservesExplicitTLSAuthName(t *testing.T) {
+	bootstrapAddr := testBootstrapServer(t, "dot.example.com", "203.0.113.1")
+
+	up := forwardUpstream{addr: "dot.example.com@853", tlsAuthName: "explicit.example.com"}
+	if err := up.bootstrap(bootstrapAddr); err != nil {
+		t.Fatalf("bootstrap returned error: %s", err)
+	}
+	if up.tlsAuthName != "explicit.example.com" {
+		t.Errorf("tlsAuthName = %q, want the explicitly configured value to be preserved", up.tlsAuthName)
+	}
+}
+
+func TestForwardUpstreamBootstrapNoopForIP(t *testing.T) {
+	up := forwardUpstream{addr: "1.1.1.1@853"}
+	if err := up.bootstrap("127.0.0.1:1"); err != nil {
+		t.Fatalf("bootstrap returned error for an already-IP addr: %s", err)
+	}
+	if up.addr != "1.1.1.1@853" {
+		t.Errorf("addr = %q, want unchanged %q", up.addr, "1.1.1.1@853")
+	}
+}
+
+func TestForwardUpstreamBootstrapRejectsURL(t *testing.T) {
+	up := forwardUpstream{addr: "https://dns.google/dns-query"}
+	if err := up.bootstrap("127.0.0.1:1"); err == nil {
+		t.Fatalf("bootstrap succeeded for a URL-style addr, wanted an error")
+	}
+	if up.addr != "https://dns.google/dns-query" {
+		t.Errorf("addr = %q, want unchanged %q", up.addr, "https://dns.google/dns-query")
+	}
+}
+
+func TestForwardUpstreamBootstrapNoAnswer(t *testing.T) {
+	bootstrapAddr := testBootstrapServer(t, "dot.example.com", "203.0.113.1")
+
+	up := forwardUpstream{addr: "other.example.com@853"}
+	if err := up.bootstrap(bootstrapAddr); err == nil {
+		t.Fatalf("bootstrap succeeded for a name the bootstrap resolver has no answer for, wanted an error")
+	}
+}
@@ -0,0 +1,67 @@
+package unbound
+
+import (
+	"sync"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/miekg/dns"
+	"golang.org/x/time/rate"
+)
+
+// ratelimitCacheCapacity bounds the number of distinct client IPs tracked at
+// once, so a flood of spoofed source addresses can't grow the limiter set
+// without bound. cache.Cache has no fancy expunge algorithm - once full it
+// just randomly evicts an existing entry to make room.
+const ratelimitCacheCapacity = 4096
+
+// ratelimiters hands out a per-client-IP token bucket, backed by a
+// capacity-bounded cache that randomly evicts entries once full.
+type ratelimiters struct {
+	qps   rate.Limit
+	burst int
+
+	mu    sync.Mutex
+	cache *cache.Cache
+}
+
+// newRatelimiters returns a ratelimiters allowing qps queries per second, per
+// client IP, with the given burst.
+func newRatelimiters(qps float64, burst int) *ratelimiters {
+	return &ratelimiters{
+		qps:   rate.Limit(qps),
+		burst: burst,
+		cache: cache.New(ratelimitCacheCapacity),
+	}
+}
+
+// allow reports whether a query from ip is within its rate limit, creating
+// that client's token bucket on first sight.
+func (r *ratelimiters) allow(ip string) bool {
+	key := cache.Hash([]byte(ip))
+
+	r.mu.Lock()
+	lim, ok := r.cache.Get(key)
+	if !ok {
+		lim = rate.NewLimiter(r.qps, r.burst)
+		r.cache.Add(key, lim)
+	}
+	r.mu.Unlock()
+
+	return lim.(*rate.Limiter).Allow()
+}
+
+// refuseAny answers r with the minimal RFC 8482 HINFO response recommended
+// for ANY queries, without ever dispatching to the resolver.
+func refuseAny(w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+
+	m.Answer = []dns.RR{&dns.HINFO{
+		Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeHINFO, Class: dns.ClassINET, Ttl: 86400},
+		Cpu: "ANY obsoleted",
+		Os:  "See RFC 8482",
+	}}
+
+	w.WriteMsg(m)
+	return 0, nil
+}
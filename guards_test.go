@@ -0,0 +1,118 @@
+package unbound
+
+import (
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestUnboundParseRefuseAny(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    bool
+		wantErr bool
+	}{
+		{name: "bare directive defaults to true", input: `unbound example.org {
+			refuse_any
+		}`, want: true},
+		{name: "explicit false", input: `unbound example.org {
+			refuse_any false
+		}`, want: false},
+		{name: "invalid value", input: `unbound example.org {
+			refuse_any maybe
+		}`, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := caddy.NewTestController("dns", tc.input)
+			u, err := unboundParse(c)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("unboundParse succeeded, wanted an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unboundParse returned error: %s", err)
+			}
+			if u.refuseAny != tc.want {
+				t.Errorf("u.refuseAny = %v, want %v", u.refuseAny, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnboundParseRatelimit(t *testing.T) {
+	c := caddy.NewTestController("dns", `unbound example.org {
+		ratelimit 10 20
+	}`)
+	u, err := unboundParse(c)
+	if err != nil {
+		t.Fatalf("unboundParse returned error: %s", err)
+	}
+	if u.ratelimiter == nil {
+		t.Fatal("u.ratelimiter is nil, want configured")
+	}
+	if u.ratelimiter.qps != 10 || u.ratelimiter.burst != 20 {
+		t.Errorf("ratelimiter = {qps:%v burst:%v}, want {qps:10 burst:20}", u.ratelimiter.qps, u.ratelimiter.burst)
+	}
+
+	if _, err := unboundParse(caddy.NewTestController("dns", `unbound example.org {
+		ratelimit notanumber
+	}`)); err == nil {
+		t.Fatalf("unboundParse succeeded with an invalid qps, wanted an error")
+	}
+}
+
+func TestRatelimitersAllow(t *testing.T) {
+	r := newRatelimiters(1, 1)
+
+	if !r.allow("127.0.0.1") {
+		t.Fatal("first query from a fresh client should be allowed")
+	}
+	if r.allow("127.0.0.1") {
+		t.Fatal("second immediate query should exceed the burst of 1 and be denied")
+	}
+	if !r.allow("127.0.0.2") {
+		t.Fatal("a different client's bucket should be independent")
+	}
+}
+
+func TestRefuseAny(t *testing.T) {
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeANY)
+
+	rec := &testResponseWriter{}
+	rcode, err := refuseAny(rec, req)
+	if err != nil {
+		t.Fatalf("refuseAny returned error: %s", err)
+	}
+	if rcode != 0 {
+		t.Errorf("rcode = %d, want 0 (response already written)", rcode)
+	}
+	if rec.msg == nil {
+		t.Fatal("refuseAny did not write a response")
+	}
+	if len(rec.msg.Answer) != 1 {
+		t.Fatalf("got %d answer RRs, want 1", len(rec.msg.Answer))
+	}
+	if _, ok := rec.msg.Answer[0].(*dns.HINFO); !ok {
+		t.Errorf("answer RR is %T, want *dns.HINFO", rec.msg.Answer[0])
+	}
+}
+
+// testResponseWriter is a minimal dns.ResponseWriter that only records the
+// message it was asked to write, for use in tests that don't need a real
+// network round-trip.
+type testResponseWriter struct {
+	dns.ResponseWriter
+	msg *dns.Msg
+}
+
+func (w *testResponseWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
@@ -0,0 +1,39 @@
+package unbound
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// DnssecResult counts the DNSSEC validation outcome libunbound reached for a query,
+// broken down per server and per matched zone so a bogus answer from one zone
+// doesn't get lost in the noise of a catch-all "." block.
+var DnssecResult = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "coredns",
+	Subsystem: "unbound",
+	Name:      "dnssec_validation_total",
+	Help:      "Counter of DNSSEC validation results per zone.",
+}, []string{"server", "zone", "state"})
+
+// RefuseAnyCount counts queries refused by the "refuse_any" guard before ever
+// reaching the resolver.
+var RefuseAnyCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "coredns",
+	Subsystem: "unbound",
+	Name:      "refused_any_total",
+	Help:      "Counter of ANY queries refused without resolution.",
+}, []string{"server"})
+
+// RateLimitedCount counts queries dropped by the "ratelimit" guard.
+var RateLimitedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "coredns",
+	Subsystem: "unbound",
+	Name:      "ratelimited_total",
+	Help:      "Counter of queries refused for exceeding the per-client rate limit.",
+}, []string{"server"})
+
+// InflightRejectedCount counts queries refused because the "inflight" bound on
+// concurrent outstanding queries was already saturated.
+var InflightRejectedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "coredns",
+	Subsystem: "unbound",
+	Name:      "inflight_rejected_total",
+	Help:      "Counter of queries refused for exceeding the inflight query bound.",
+}, []string{"server"})
@@ -0,0 +1,166 @@
+package unbound
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mangadex-pub/go-libunbound"
+)
+
+// gracePeriod is how long a superseded libunbound context pair is kept alive
+// after a reload swap, giving in-flight Resolve calls time to finish before
+// it's destroyed.
+const gracePeriod = 5 * time.Second
+
+// unboundCtx bundles the pair of libunbound contexts used for UDP and TCP
+// queries. reload swaps Unbound.ctx out from under ServeDNS atomically, so a
+// request either sees the old pair in full or the new one, never a mix.
+type unboundCtx struct {
+	u *unbound.Unbound
+	t *unbound.Unbound
+}
+
+// set applies option k=v to both contexts in c, without recording it anywhere -
+// callers that need the option replayed on reload are responsible for keeping
+// their own record (see Unbound.opts, forwardZones) and replaying it in order.
+func (c *unboundCtx) set(k, v string) error {
+	k += ":"
+	c.u.SetOption(k, v)
+	if err := c.t.SetOption(k, v); err != nil {
+		return fmt.Errorf("failed to set option %q with value %q: %s", k, v, err)
+	}
+	return nil
+}
+
+// buildCtx constructs a fresh pair of libunbound contexts and replays every
+// option, config file, anchor file and forward zone recorded on u, grouped by
+// kind in that order. Config files, anchor files and forward zones keep the
+// order they were configured in; options come from the u.opts map, so only
+// the last value per key is replayed and the order between options is not
+// defined. Used by reload to rebuild the live pair after a watched file
+// changes.
+func (u *Unbound) buildCtx() (*unboundCtx, error) {
+	pair := newCtxPair()
+	udp, tcp := pair.u, pair.t
+
+	for k, v := range u.opts {
+		k += ":"
+		udp.SetOption(k, v)
+		if err := tcp.SetOption(k, v); err != nil {
+			udp.Destroy()
+			tcp.Destroy()
+			return nil, fmt.Errorf("failed to set option %q with value %q: %s", k, v, err)
+		}
+	}
+	for _, f := range u.configFiles {
+		if err := udp.Config(f); err != nil {
+			udp.Destroy()
+			tcp.Destroy()
+			return nil, fmt.Errorf("failed to read config file (%s) UDP context: %s", f, err)
+		}
+		if err := tcp.Config(f); err != nil {
+			udp.Destroy()
+			tcp.Destroy()
+			return nil, fmt.Errorf("failed to read config file (%s) TCP context: %s", f, err)
+		}
+	}
+	for _, f := range u.anchorFiles {
+		if err := udp.AddTaFile(f); err != nil {
+			udp.Destroy()
+			tcp.Destroy()
+			return nil, fmt.Errorf("failed to read trust anchor file (%s) UDP context: %s", f, err)
+		}
+		if err := tcp.AddTaFile(f); err != nil {
+			udp.Destroy()
+			tcp.Destroy()
+			return nil, fmt.Errorf("failed to read trust anchor file (%s) TCP context: %s", f, err)
+		}
+	}
+	// Forward zones are replayed last, and in the order they were configured:
+	// libunbound's forward-zone parsing is stateful, each "name:"/"forward-addr:"
+	// sequence must immediately follow the "forward-zone:" that opens its section.
+	for _, fz := range u.forwardZones {
+		if err := applyForward(pair, fz); err != nil {
+			udp.Destroy()
+			tcp.Destroy()
+			return nil, fmt.Errorf("failed to replay forward zone %s: %s", fz.zone, err)
+		}
+	}
+	return pair, nil
+}
+
+// watch starts watching every recorded config and anchor file for changes,
+// rebuilding and swapping in a fresh unboundCtx whenever one changes. Changes
+// are debounced by u.reload so a burst of writes (e.g. an atomic file
+// replacement) only triggers a single rebuild. It is a no-op if the "reload"
+// directive wasn't used.
+func (u *Unbound) watch() error {
+	if u.reload == 0 {
+		return nil
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start config watcher: %s", err)
+	}
+
+	watched := append(append([]string{}, u.configFiles...), u.anchorFiles...)
+	for _, f := range watched {
+		if err := w.Add(f); err != nil {
+			w.Close()
+			return fmt.Errorf("failed to watch %s for changes: %s", f, err)
+		}
+	}
+	u.watcher = w
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					// Atomic file replacement (e.g. a root.key rotation) drops the
+					// watch on the old inode; best-effort re-add it once the
+					// replacement has landed so future changes keep being seen.
+					go func(name string) {
+						time.Sleep(100 * time.Millisecond)
+						w.Add(name)
+					}(event.Name)
+				}
+				if timer == nil {
+					timer = time.AfterFunc(u.reload, u.rebuild)
+					continue
+				}
+				timer.Reset(u.reload)
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Warningf("config watcher error: %s", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// rebuild constructs a fresh libunbound context pair from the currently
+// recorded options/config/anchor files and atomically swaps it in, keeping the
+// superseded pair alive for gracePeriod so in-flight queries finish cleanly.
+func (u *Unbound) rebuild() {
+	c, err := u.buildCtx()
+	if err != nil {
+		log.Errorf("failed to reload unbound config, keeping previous context: %s", err)
+		return
+	}
+
+	old := u.ctx.Swap(c)
+	time.AfterFunc(gracePeriod, func() {
+		old.u.Destroy()
+		old.t.Destroy()
+	})
+	log.Infof("reloaded unbound configuration")
+}
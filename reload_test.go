@@ -0,0 +1,26 @@
+package unbound
+
+import (
+	"testing"
+
+	"github.com/coredns/caddy"
+)
+
+func TestUnboundParseReload(t *testing.T) {
+	c := caddy.NewTestController("dns", `unbound example.org {
+		reload 30s
+	}`)
+	u, err := unboundParse(c)
+	if err != nil {
+		t.Fatalf("unboundParse returned error: %s", err)
+	}
+	if u.reload.String() != "30s" {
+		t.Errorf("u.reload = %s, want 30s", u.reload)
+	}
+
+	if _, err := unboundParse(caddy.NewTestController("dns", `unbound example.org {
+		reload notaduration
+	}`)); err == nil {
+		t.Fatalf("unboundParse succeeded with an invalid duration, wanted an error")
+	}
+}
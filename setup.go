@@ -2,6 +2,9 @@ package unbound
 
 import (
 	"errors"
+	"strconv"
+	"time"
+
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
@@ -35,10 +38,15 @@ func setup(c *caddy.Controller) error {
 			if x, ok := m.(*metrics.Metrics); ok {
 				x.MustRegister(RequestDuration)
 				x.MustRegister(RcodeCount)
+				x.MustRegister(DnssecResult)
+				x.MustRegister(RefuseAnyCount)
+				x.MustRegister(RateLimitedCount)
+				x.MustRegister(InflightRejectedCount)
 			}
 		})
 		return nil
 	})
+	c.OnStartup(u.watch)
 	c.OnShutdown(u.Stop)
 
 	return nil
@@ -121,7 +129,123 @@ func unboundParse(c *caddy.Controller) (*Unbound, error) {
 				if err = u.setAnchor(args[0]); err != nil {
 					return nil, err
 				}
-				u.strict = true
+				// Preserve the historical behaviour of "anchor" implying strict
+				// validation, unless "strict" was already used to scope it.
+				if u.strict == nil {
+					u.strict = append(u.strict, u.from...)
+				}
+			case "strict":
+				args = c.RemainingArgs()
+				if len(args) == 0 {
+					u.strict = append(u.strict, u.from...)
+					break
+				}
+				for i := range args {
+					host, err := normalizeHost("strict", args[i])
+					if err != nil {
+						return nil, err
+					}
+					args[i] = *host
+				}
+				u.strict = append(u.strict, args...)
+			case "reload":
+				args = c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, c.Errf("invalid reload duration %q: %s", args[0], err)
+				}
+				u.reload = d
+			case "forward":
+				args = c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				zone, err := normalizeHost("forward", args[0])
+				if err != nil {
+					return nil, err
+				}
+
+				fz := forwardZone{zone: *zone}
+				for c.NextBlock() {
+					fargs := c.RemainingArgs()
+					if len(fargs) != 1 {
+						return nil, c.ArgErr()
+					}
+					switch c.Val() {
+					case "addr":
+						fz.upstreams = append(fz.upstreams, forwardUpstream{addr: fargs[0]})
+					case "tls-auth-name":
+						if len(fz.upstreams) == 0 {
+							return nil, c.Errf("tls-auth-name must follow an addr")
+						}
+						fz.upstreams[len(fz.upstreams)-1].tlsAuthName = fargs[0]
+					case "tls":
+						if len(fz.upstreams) == 0 {
+							return nil, c.Errf("tls must follow an addr")
+						}
+						fz.upstreams[len(fz.upstreams)-1].tls = fargs[0] == "yes"
+					case "doh":
+						if len(fz.upstreams) == 0 {
+							return nil, c.Errf("doh must follow an addr")
+						}
+						fz.upstreams[len(fz.upstreams)-1].doh = fargs[0] == "yes"
+					case "bootstrap":
+						if len(fz.upstreams) == 0 {
+							return nil, c.Errf("bootstrap must follow an addr")
+						}
+						if err := fz.upstreams[len(fz.upstreams)-1].bootstrap(fargs[0]); err != nil {
+							return nil, err
+						}
+					default:
+						return nil, c.ArgErr()
+					}
+				}
+				if err := u.addForward(fz); err != nil {
+					return nil, err
+				}
+			case "refuse_any":
+				args = c.RemainingArgs()
+				if len(args) > 1 {
+					return nil, c.ArgErr()
+				}
+				if len(args) == 1 && args[0] != "true" && args[0] != "false" {
+					return nil, c.Errf("invalid refuse_any value %q", args[0])
+				}
+				u.refuseAny = len(args) == 0 || args[0] == "true"
+			case "ratelimit":
+				args = c.RemainingArgs()
+				if len(args) < 1 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				qps, err := strconv.ParseFloat(args[0], 64)
+				if err != nil || qps <= 0 {
+					return nil, c.Errf("invalid ratelimit qps %q", args[0])
+				}
+				burst := int(qps)
+				if len(args) == 2 {
+					b, err := strconv.Atoi(args[1])
+					if err != nil || b < 1 {
+						return nil, c.Errf("invalid ratelimit burst %q", args[1])
+					}
+					burst = b
+				}
+				if burst < 1 {
+					burst = 1
+				}
+				u.ratelimiter = newRatelimiters(qps, burst)
+			case "inflight":
+				args = c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(args[0])
+				if err != nil || n < 1 {
+					return nil, c.Errf("invalid inflight value %q", args[0])
+				}
+				u.inflight = make(chan struct{}, n)
 			default:
 				return nil, c.ArgErr()
 			}
@@ -0,0 +1,70 @@
+package unbound
+
+import (
+	"testing"
+
+	"github.com/coredns/caddy"
+)
+
+func TestUnboundParseStrict(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantStrict []string
+	}{
+		{
+			name: "anchor implies strict for all from zones",
+			input: `unbound example.org {
+				anchor testdata/root.key
+			}`,
+			wantStrict: []string{"example.org."},
+		},
+		{
+			name: "strict with no args applies to all from zones",
+			input: `unbound example.org {
+				strict
+			}`,
+			wantStrict: []string{"example.org."},
+		},
+		{
+			name: "strict scoped to a sub-zone",
+			input: `unbound example.org {
+				strict ipv6.arpa
+			}`,
+			wantStrict: []string{"ipv6.arpa."},
+		},
+		{
+			name: "explicit strict wins over anchor's implicit global strict",
+			input: `unbound example.org {
+				strict ipv6.arpa
+				anchor testdata/root.key
+			}`,
+			wantStrict: []string{"ipv6.arpa."},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := caddy.NewTestController("dns", tc.input)
+			u, err := unboundParse(c)
+			if err != nil {
+				t.Fatalf("unboundParse returned error: %s", err)
+			}
+			if !equalStrings(u.strict, tc.wantStrict) {
+				t.Errorf("u.strict = %v, want %v", u.strict, tc.wantStrict)
+			}
+		})
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
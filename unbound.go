@@ -6,6 +6,10 @@ import (
 	"fmt"
 	"github.com/mangadex-pub/go-libunbound"
 	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/metrics"
@@ -19,12 +23,43 @@ var log = clog.NewWithPlugin("unbound")
 
 // Unbound is a plugin that resolves requests using libunbound.
 type Unbound struct {
-	u *unbound.Unbound
-	t *unbound.Unbound
+	// ctx holds the live pair of libunbound contexts. It's behind an atomic
+	// pointer so reload (see reload.go) can swap in a freshly built pair
+	// without ServeDNS ever observing a half-initialized one.
+	ctx atomic.Pointer[unboundCtx]
 
 	from   []string
 	except []string
-	strict bool
+
+	// strict holds the zones for which a bogus DNSSEC validation result is hard-failed
+	// (SERVFAIL returned to the client) instead of just being logged and counted via
+	// DnssecResult. Set from the "strict" directive, or implicitly to u.from by an
+	// "anchor" that no "strict" directive precedes.
+	strict []string
+
+	// opts, configFiles, anchorFiles and forwardZones record every option/config/
+	// anchor/forward applied so far, so reload.go can replay them all against a
+	// freshly built unboundCtx. opts keeps the last value per key, without order.
+	opts         map[string]string
+	configFiles  []string
+	anchorFiles  []string
+	forwardZones []forwardZone
+
+	// reload is the debounce interval used when watching configFiles/anchorFiles for
+	// changes; zero (the default) disables hot-reload entirely.
+	reload  time.Duration
+	watcher *fsnotify.Watcher
+
+	// refuseAny, when set, answers ANY queries with the RFC 8482 HINFO response
+	// directly, never dispatching them to the resolver.
+	refuseAny bool
+	// ratelimiter, when set (via the "ratelimit" directive), bounds queries per
+	// client IP; nil disables rate limiting entirely.
+	ratelimiter *ratelimiters
+
+	// inflight, when set (via the "inflight" directive), bounds the number of
+	// queries concurrently outstanding against libunbound; nil means unbounded.
+	inflight chan struct{}
 
 	Next plugin.Handler
 }
@@ -37,11 +72,8 @@ var options = map[string]string{
 
 // New returns a pointer to an initialzed Unbound.
 func New() *Unbound {
-	udp := unbound.New()
-	tcp := unbound.New()
-	tcp.SetOption("tcp-upstream:", "yes")
-
-	u := &Unbound{u: udp, t: tcp}
+	u := &Unbound{opts: map[string]string{}}
+	u.ctx.Store(newCtxPair())
 
 	for k, v := range options {
 		if err := u.setOption(k, v); err != nil {
@@ -52,58 +84,78 @@ func New() *Unbound {
 	return u
 }
 
-// Stop stops unbound and cleans up the memory used.
+// Stop stops unbound and cleans up the memory used. The actual Destroy is
+// delayed by gracePeriod, the same grace given to a superseded pair on reload
+// (see reload.go), so queries already in flight get a chance to complete
+// instead of being orphaned by their context disappearing out from under them.
 func (u *Unbound) Stop() error {
-	u.u.Destroy()
-	u.t.Destroy()
+	if u.watcher != nil {
+		u.watcher.Close()
+	}
+	c := u.ctx.Load()
+	time.AfterFunc(gracePeriod, func() {
+		c.u.Destroy()
+		c.t.Destroy()
+	})
 	return nil
 }
 
-// setOption sets option k to value v in u.
+// setOption sets option k to value v in u, recording it so it can be replayed
+// against a freshly built unboundCtx on reload.
 func (u *Unbound) setOption(k, v string) error {
+	u.opts[k] = v
+
+	c := u.ctx.Load()
 	// Add ":" as unbound expects it
 	k += ":"
 	// Set for both udp and tcp handlers, return the error from the latter.
-	u.u.SetOption(k, v)
-	err := u.t.SetOption(k, v)
+	c.u.SetOption(k, v)
+	err := c.t.SetOption(k, v)
 	if err != nil {
 		return fmt.Errorf("failed to set option %q with value %q: %s", k, v, err)
 	}
 	return nil
 }
 
-// config reads the file f and sets unbound configuration
+// config reads the file f and sets unbound configuration. f is recorded so it
+// can be replayed, and watched for changes, on reload.
 func (u *Unbound) config(f string) error {
-	var err error
+	c := u.ctx.Load()
 
-	err = u.u.Config(f)
-	if err != nil {
+	if err := c.u.Config(f); err != nil {
 		return fmt.Errorf("failed to read config file (%s) UDP context: %s", f, err)
 	}
-
-	err = u.t.Config(f)
-	if err != nil {
+	if err := c.t.Config(f); err != nil {
 		return fmt.Errorf("failed to read config file (%s) TCP context: %s", f, err)
 	}
+
+	u.configFiles = append(u.configFiles, f)
 	return nil
 }
 
-// anchor reads the file f and sets it as anchor
+// setAnchor reads the file f and sets it as anchor. f is recorded so it can be
+// replayed, and watched for changes, on reload.
 func (u *Unbound) setAnchor(f string) error {
-	var err error
+	c := u.ctx.Load()
 
-	err = u.u.AddTaFile(f)
-	if err != nil {
+	if err := c.u.AddTaFile(f); err != nil {
 		return fmt.Errorf("failed to read trust anchor file (%s) UDP context: %s", f, err)
 	}
-
-	err = u.t.AddTaFile(f)
-	if err != nil {
+	if err := c.t.AddTaFile(f); err != nil {
 		return fmt.Errorf("failed to read trust anchor file (%s) TCP context: %s", f, err)
 	}
+
+	u.anchorFiles = append(u.anchorFiles, f)
 	return nil
 }
 
+// isStrict reports whether zone was configured (via the "strict" directive, or
+// implicitly via "anchor") to hard-fail bogus DNSSEC answers rather than just
+// logging and counting them.
+func (u *Unbound) isStrict(zone string) bool {
+	return plugin.Zones(u.strict).Matches(zone) != ""
+}
+
 // ServeDNS implements the plugin.Handler interface.
 func (u *Unbound) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
 	state := request.Request{W: w, Req: r}
@@ -112,15 +164,44 @@ func (u *Unbound) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 		return plugin.NextOrFailure(u.Name(), u.Next, ctx, w, r)
 	}
 
+	zone := plugin.Zones(u.from).Matches(state.Name())
+	if zone == "" {
+		zone = "."
+	}
+
+	server := metrics.WithServer(ctx)
+
+	if u.refuseAny && state.QType() == dns.TypeANY {
+		RefuseAnyCount.WithLabelValues(server).Add(1)
+		return refuseAny(w, r)
+	}
+	if u.ratelimiter != nil && !u.ratelimiter.allow(state.IP()) {
+		RateLimitedCount.WithLabelValues(server).Add(1)
+		return dns.RcodeRefused, nil
+	}
+	release := func() {}
+	if u.inflight != nil {
+		select {
+		case u.inflight <- struct{}{}:
+			release = func() { <-u.inflight }
+		default:
+			InflightRejectedCount.WithLabelValues(server).Add(1)
+			return dns.RcodeRefused, nil
+		}
+	}
+
+	c := u.ctx.Load()
+
+	start := time.Now()
 	var (
 		res *unbound.Result
 		err error
 	)
 	switch state.Proto() {
 	case "tcp":
-		res, err = u.t.Resolve(state.QName(), state.QType(), state.QClass())
+		res, err = resolve(ctx, c.t, state.QName(), state.QType(), state.QClass(), release)
 	case "udp":
-		res, err = u.u.Resolve(state.QName(), state.QType(), state.QClass())
+		res, err = resolve(ctx, c.u, state.QName(), state.QType(), state.QClass(), release)
 	}
 
 	rcode := dns.RcodeServerFailure
@@ -132,15 +213,40 @@ func (u *Unbound) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 		rc = strconv.Itoa(rcode)
 	}
 
-	server := metrics.WithServer(ctx)
+	// A query cancelled before libunbound answered has no res to read Rtt from;
+	// fall back to wall-clock elapsed so operators can still see upstream tail
+	// latency for abandoned queries.
+	dur := time.Since(start)
+	if res != nil {
+		dur = res.Rtt
+	}
 	RcodeCount.WithLabelValues(server, rc).Add(1)
-	RequestDuration.WithLabelValues(server).Observe(res.Rtt.Seconds())
+	RequestDuration.WithLabelValues(server).Observe(dur.Seconds())
 
-	if err != nil || res.AnswerPacket.Question == nil {
+	if err != nil || res == nil || res.AnswerPacket.Question == nil {
 		return dns.RcodeServerFailure, err
 	}
-	if u.strict && res.Bogus {
-		return dns.RcodeServerFailure, errors.New(res.WhyBogus)
+
+	// Classify the validation outcome from Secure/Bogus; an answer that is neither
+	// and carries SERVFAIL is indeterminate. NXDOMAIN is not a signal here: an
+	// ordinary nonexistent name in an unsigned zone is Secure=false/Bogus=false
+	// too, same as any other insecure answer.
+	vstate := "insecure"
+	switch {
+	case res.Bogus:
+		vstate = "bogus"
+	case res.Secure:
+		vstate = "secure"
+	case rcode == dns.RcodeServerFailure:
+		vstate = "indeterminate"
+	}
+	DnssecResult.WithLabelValues(server, zone, vstate).Add(1)
+
+	if res.Bogus {
+		if u.isStrict(zone) {
+			return dns.RcodeServerFailure, errors.New(res.WhyBogus)
+		}
+		log.Warningf("bogus DNSSEC answer for %s in non-strict zone %s: %s", state.QName(), zone, res.WhyBogus)
 	}
 	// If the client *didn't* set the opt record, and specifically not the DO bit,
 	// strip this from the reply (unbound default to setting DO).